@@ -3,14 +3,28 @@ package bus
 import (
 	"sort"
 	"sync"
+	"sync/atomic"
 
 	"github.com/tinywasm/binary"
 )
 
 type Bus interface {
-	// Subscribe registers a handler for a topic.
-	// Returns a Subscription handle to cancel the registration.
-	Subscribe(topic string, handler func(msg binary.Message)) Subscription
+	// Subscribe registers a handler for a topic. Delivery runs on a
+	// dedicated worker goroutine fed by a bounded queue; use WithQueueSize
+	// and WithOverflowPolicy to configure it. Returns a Subscription
+	// handle to cancel the registration.
+	Subscribe(topic string, handler func(msg binary.Message), opts ...SubscribeOption) Subscription
+
+	// SubscribeChan registers ch to receive messages published to topic.
+	// Publish performs a non-blocking send to ch; see ChanSubscription for
+	// drop accounting when ch can't keep up.
+	SubscribeChan(topic string, ch chan<- binary.Message) Subscription
+
+	// SubscribeWithReplay subscribes like Subscribe, then immediately
+	// delivers up to n of the most recently cached messages for topic.
+	// The bus must be constructed with WithCache for there to be any
+	// history to replay.
+	SubscribeWithReplay(topic string, handler func(msg binary.Message), n int, opts ...SubscribeOption) Subscription
 
 	// Publish sends a message to all subscribers of a topic.
 	Publish(topic string, msg binary.Message) error
@@ -18,6 +32,11 @@ type Bus interface {
 	// Topics returns a sorted list of all active topics.
 	Topics() []string
 
+	// MessageCounts returns the number of cached messages retained per
+	// topic. It is always empty unless the bus was constructed with
+	// WithCache.
+	MessageCounts() map[string]int
+
 	// Close shuts down the bus and clears all registrations.
 	Close() error
 }
@@ -27,70 +46,127 @@ type Subscription interface {
 	Cancel()
 }
 
-// Internal: slices, not maps (TinyGo binary size + simplicity)
-type topicEntry struct {
-	topic string
-	subs  []subscriber
-}
-
 type subscriber struct {
 	id      uint32
+	pattern string // the topic pattern passed to Subscribe/SubscribeChan
 	handler func(msg binary.Message)
+
+	// queue, done and policy back the bounded delivery queue used for
+	// handler subscribers registered via Subscribe.
+	queue   chan binary.Message
+	done    chan struct{}
+	policy  OverflowPolicy
+	dropped *uint64
+
+	// ch is set instead of handler/queue for channel subscribers
+	// registered via SubscribeChan.
+	ch chan<- binary.Message
 }
 
 type bus struct {
 	mu     sync.RWMutex
-	topics []topicEntry // O(n) scan — fine for typical topic counts
+	root   *topicNode // trie of topic segments; see topic.go
 	nextID uint32
+
+	// cache, cacheMu and cacheSize back the optional per-topic replay
+	// cache configured via WithCache; see cache.go.
+	cacheMu   sync.Mutex
+	cache     map[string][]binary.Message
+	cacheSize int
+
+	// typedMu and typed back the per-(topic, value type) correlation-ID
+	// registries used by Typed[M]; see typed.go. Owning this on the bus
+	// itself, rather than in a package-level registry, means it's
+	// discarded for good in Close() instead of outliving the bus.
+	typedMu sync.Mutex
+	typed   map[typedStateKey]interface{}
 }
 
-// New creates a new in-memory bus.
-func New() Bus {
-	return &bus{}
+// New creates a new in-memory bus, applying any Options given.
+func New(opts ...Option) Bus {
+	b := &bus{root: &topicNode{}}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
 }
 
-func (b *bus) Subscribe(topic string, handler func(msg binary.Message)) Subscription {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+// newHandlerSubscriber builds a subscriber for topic without making it
+// visible to Publish; the caller inserts it into the trie (and starts its
+// worker) once it's ready to receive. SubscribeWithReplay uses the gap
+// between the two to prime the queue with replay history before any live
+// publish can reach it.
+func (b *bus) newHandlerSubscriber(topic string, handler func(msg binary.Message), opts ...SubscribeOption) subscriber {
+	cfg := subscribeConfig{queueSize: defaultQueueSize, policy: DropNewest}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 
+	b.mu.Lock()
 	b.nextID++
 	id := b.nextID
-	sub := subscriber{id: id, handler: handler}
-
-	found := false
-	for i := range b.topics {
-		if b.topics[i].topic == topic {
-			b.topics[i].subs = append(b.topics[i].subs, sub)
-			found = true
-			break
-		}
+	b.mu.Unlock()
+
+	return subscriber{
+		id:      id,
+		pattern: topic,
+		handler: handler,
+		queue:   make(chan binary.Message, cfg.queueSize),
+		done:    make(chan struct{}),
+		policy:  cfg.policy,
+		dropped: new(uint64),
 	}
+}
 
-	if !found {
-		b.topics = append(b.topics, topicEntry{
-			topic: topic,
-			subs:  []subscriber{sub},
-		})
-	}
+// insertSubscriber registers sub in the trie under topic, making it visible
+// to Publish.
+func (b *bus) insertSubscriber(topic string, sub subscriber) {
+	b.mu.Lock()
+	b.root.insert(splitTopic(topic), sub)
+	b.mu.Unlock()
+}
+
+func (b *bus) Subscribe(topic string, handler func(msg binary.Message), opts ...SubscribeOption) Subscription {
+	sub := b.newHandlerSubscriber(topic, handler, opts...)
+	b.insertSubscriber(topic, sub)
+	go runQueueWorker(sub.queue, sub.done, sub.handler)
 
-	return &subscription{
-		bus:   b,
-		topic: topic,
-		id:    id,
+	return &queuedSubscription{
+		subscription: subscription{
+			bus:   b,
+			topic: topic,
+			id:    sub.id,
+			done:  sub.done,
+		},
+		queue:   sub.queue,
+		dropped: sub.dropped,
 	}
 }
 
 func (b *bus) Publish(topic string, msg binary.Message) error {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+	b.recordCache(topic, msg)
 
-	for _, entry := range b.topics {
-		if entry.topic == topic {
-			for _, sub := range entry.subs {
-				go sub.handler(msg)
+	b.mu.RLock()
+	// match appends copies of matching subscriber values, so the result
+	// is safe to use after unlocking even though Cancel mutates the trie
+	// in place under the write lock.
+	var subs []subscriber
+	b.root.match(splitTopic(topic), &subs)
+	b.mu.RUnlock()
+
+	// Deliver outside the lock: a Block-policy subscriber can make this
+	// call wait, and it must not hold up Subscribe/Cancel/Publish for
+	// other topics while it does.
+	for _, sub := range subs {
+		if sub.ch != nil {
+			select {
+			case sub.ch <- msg:
+			default:
+				atomic.AddUint64(sub.dropped, 1)
 			}
-			break
+			continue
 		}
+		sub.enqueue(msg)
 	}
 	return nil
 }
@@ -99,11 +175,12 @@ func (b *bus) Topics() []string {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	topics := make([]string, 0, len(b.topics))
-	for _, entry := range b.topics {
-		if len(entry.subs) > 0 {
-			topics = append(topics, entry.topic)
-		}
+	seen := make(map[string]struct{})
+	b.root.collectPatterns(seen)
+
+	topics := make([]string, 0, len(seen))
+	for topic := range seen {
+		topics = append(topics, topic)
 	}
 	sort.Strings(topics)
 	return topics
@@ -111,8 +188,28 @@ func (b *bus) Topics() []string {
 
 func (b *bus) Close() error {
 	b.mu.Lock()
-	defer b.mu.Unlock()
-	b.topics = nil
+	var dones []chan struct{}
+	b.root.collectDone(&dones)
+	b.root = &topicNode{}
+	b.mu.Unlock()
+
+	// Stop every handler subscriber's worker goroutine now that no future
+	// publish can reach it. Collecting and swapping the trie under the
+	// same lock acquisition means a concurrent Cancel either removed its
+	// subscriber first (so it's absent here) or sees the now-empty trie
+	// and skips the close itself, so each done channel is closed exactly
+	// once.
+	for _, done := range dones {
+		close(done)
+	}
+
+	b.cacheMu.Lock()
+	b.cache = nil
+	b.cacheMu.Unlock()
+
+	b.typedMu.Lock()
+	b.typed = nil
+	b.typedMu.Unlock()
 	return nil
 }
 
@@ -120,6 +217,7 @@ type subscription struct {
 	bus   *bus
 	topic string
 	id    uint32
+	done  chan struct{} // non-nil for queued handler subscriptions
 }
 
 func (s *subscription) Topic() string {
@@ -128,23 +226,12 @@ func (s *subscription) Topic() string {
 
 func (s *subscription) Cancel() {
 	s.bus.mu.Lock()
-	defer s.bus.mu.Unlock()
-
-	for i := range s.bus.topics {
-		if s.bus.topics[i].topic == s.topic {
-			subs := s.bus.topics[i].subs
-			for j := range subs {
-				if subs[j].id == s.id {
-					// Remove subscriber
-					s.bus.topics[i].subs = append(subs[:j], subs[j+1:]...)
-					break
-				}
-			}
-			// Remove topic if no subs left
-			if len(s.bus.topics[i].subs) == 0 {
-				s.bus.topics = append(s.bus.topics[:i], s.bus.topics[i+1:]...)
-			}
-			break
-		}
+	removed := s.bus.root.remove(splitTopic(s.topic), s.id)
+	s.bus.mu.Unlock()
+
+	// Stop the delivery worker (if any) now that no future publish can
+	// reach it; this also unblocks a producer waiting on a Block policy.
+	if removed && s.done != nil {
+		close(s.done)
 	}
 }
@@ -0,0 +1,107 @@
+package bus
+
+import (
+	"sync/atomic"
+
+	"github.com/tinywasm/binary"
+)
+
+// Option configures a bus at construction time.
+type Option func(*bus)
+
+// WithCache enables a per-topic message cache holding the last
+// maxPerTopic published messages, so later calls to SubscribeWithReplay
+// can catch new subscribers up on recent history. Caching is off by
+// default (maxPerTopic <= 0 is a no-op) to keep the zero-config bus
+// footprint small.
+func WithCache(maxPerTopic int) Option {
+	return func(b *bus) { b.cacheSize = maxPerTopic }
+}
+
+// recordCache appends msg to topic's cache, dropping the oldest message
+// once the cache is full. It is a no-op when the cache is disabled.
+func (b *bus) recordCache(topic string, msg binary.Message) {
+	if b.cacheSize <= 0 {
+		return
+	}
+
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+
+	if b.cache == nil {
+		b.cache = make(map[string][]binary.Message)
+	}
+	msgs := append(b.cache[topic], msg)
+	if len(msgs) > b.cacheSize {
+		msgs = msgs[len(msgs)-b.cacheSize:]
+	}
+	b.cache[topic] = msgs
+}
+
+// replay returns up to n of the most recently cached messages for topic,
+// oldest first. n <= 0 returns the whole cached backlog.
+func (b *bus) replay(topic string, n int) []binary.Message {
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+
+	cached := b.cache[topic]
+	if n > 0 && n < len(cached) {
+		cached = cached[len(cached)-n:]
+	}
+
+	out := make([]binary.Message, len(cached))
+	copy(out, cached)
+	return out
+}
+
+// SubscribeWithReplay subscribes handler to topic exactly like Subscribe,
+// then immediately delivers up to n of the most recently cached messages
+// for topic so a late-joining subscriber can catch up. It returns no
+// history when the bus was constructed without WithCache.
+//
+// Replay messages are pushed onto the subscriber's queue before the
+// subscriber is inserted into the trie, so no concurrent Publish can
+// reach it first: replay is always delivered ahead of any live message,
+// by the same runQueueWorker goroutine that delivers everything else for
+// this subscriber, so a handler is never entered concurrently by a replay
+// message and a live one. Replay uses the same non-blocking, drop-and-count
+// semantics as DropNewest rather than the subscriber's configured overflow
+// policy, since at this point nothing has drained the queue yet.
+func (b *bus) SubscribeWithReplay(topic string, handler func(msg binary.Message), n int, opts ...SubscribeOption) Subscription {
+	sub := b.newHandlerSubscriber(topic, handler, opts...)
+
+	for _, msg := range b.replay(topic, n) {
+		select {
+		case sub.queue <- msg:
+		default:
+			atomic.AddUint64(sub.dropped, 1)
+		}
+	}
+
+	b.insertSubscriber(topic, sub)
+	go runQueueWorker(sub.queue, sub.done, sub.handler)
+
+	return &queuedSubscription{
+		subscription: subscription{
+			bus:   b,
+			topic: topic,
+			id:    sub.id,
+			done:  sub.done,
+		},
+		queue:   sub.queue,
+		dropped: sub.dropped,
+	}
+}
+
+// MessageCounts returns the number of cached messages retained per topic
+// in a single pass, so callers don't need to poll each topic individually.
+func (b *bus) MessageCounts() map[string]int {
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+
+	counts := make(map[string]int, len(b.cache))
+	for topic, msgs := range b.cache {
+		counts[topic] = len(msgs)
+	}
+	return counts
+}
@@ -0,0 +1,59 @@
+package bus
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/tinywasm/binary"
+)
+
+func TestCacheReplayOnSubscribe(t *testing.T) {
+	b := New(WithCache(2))
+	defer b.Close()
+
+	for _, payload := range []string{"a", "b", "c"} {
+		msg := binary.Message{Topic: "test", Payload: []byte(payload)}
+		if err := b.Publish("test", msg); err != nil {
+			t.Fatalf("publish failed: %v", err)
+		}
+	}
+
+	counts := b.MessageCounts()
+	if counts["test"] != 2 {
+		t.Errorf("expected 2 cached messages (cap 2), got %d", counts["test"])
+	}
+
+	var mu sync.Mutex
+	var replayed []string
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	sub := b.SubscribeWithReplay("test", func(msg binary.Message) {
+		mu.Lock()
+		replayed = append(replayed, string(msg.Payload))
+		mu.Unlock()
+		wg.Done()
+	}, 1)
+	defer sub.Cancel()
+
+	wg.Wait()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(replayed) != 1 || replayed[0] != "c" {
+		t.Errorf("expected replay of [c], got %v", replayed)
+	}
+}
+
+func TestCacheDisabledByDefault(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	if err := b.Publish("test", binary.Message{Topic: "test", Payload: []byte("a")}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	counts := b.MessageCounts()
+	if len(counts) != 0 {
+		t.Errorf("expected no cached messages without WithCache, got %v", counts)
+	}
+}
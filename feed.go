@@ -0,0 +1,48 @@
+package bus
+
+import (
+	"sync/atomic"
+
+	"github.com/tinywasm/binary"
+)
+
+// ChanSubscription is returned by SubscribeChan. It extends Subscription
+// with a drop counter so callers can detect a channel that isn't keeping
+// up without blocking the publisher.
+type ChanSubscription interface {
+	Subscription
+
+	// Dropped returns the number of messages that were discarded because
+	// the subscriber's channel was full at publish time.
+	Dropped() uint64
+}
+
+// SubscribeChan registers ch to receive messages published to topic.
+// Unlike Subscribe, delivery does not go through a handler callback:
+// Publish performs a non-blocking send on ch and counts a drop instead of
+// blocking or spawning a goroutine when ch is full. This gives callers a
+// select-able, back-pressure-aware alternative modeled on go-ethereum's
+// event.Feed.
+func (b *bus) SubscribeChan(topic string, ch chan<- binary.Message) Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	id := b.nextID
+	sub := subscriber{id: id, pattern: topic, ch: ch, dropped: new(uint64)}
+	b.root.insert(splitTopic(topic), sub)
+
+	return &chanSubscription{
+		subscription: subscription{bus: b, topic: topic, id: id},
+		dropped:      sub.dropped,
+	}
+}
+
+type chanSubscription struct {
+	subscription
+	dropped *uint64
+}
+
+func (s *chanSubscription) Dropped() uint64 {
+	return atomic.LoadUint64(s.dropped)
+}
@@ -0,0 +1,79 @@
+package bus
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/binary"
+)
+
+func TestFeedSlowConsumer(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	ch := make(chan binary.Message, 1)
+	sub := b.SubscribeChan("test", ch)
+
+	msg := binary.Message{Topic: "test", Payload: []byte("a")}
+
+	// First send fills the buffered channel; the rest should be dropped
+	// instead of blocking Publish.
+	for i := 0; i < 5; i++ {
+		if err := b.Publish("test", msg); err != nil {
+			t.Fatalf("publish failed: %v", err)
+		}
+	}
+
+	chanSub, ok := sub.(ChanSubscription)
+	if !ok {
+		t.Fatalf("expected ChanSubscription, got %T", sub)
+	}
+	if got := chanSub.Dropped(); got != 4 {
+		t.Errorf("expected 4 dropped messages, got %d", got)
+	}
+}
+
+func TestFeedCancelDuringSend(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	ch := make(chan binary.Message)
+	sub := b.SubscribeChan("test", ch)
+	msg := binary.Message{Topic: "test", Payload: []byte("a")}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := b.Publish("test", msg); err != nil {
+			t.Errorf("publish failed: %v", err)
+		}
+	}()
+
+	// No one is reading ch, so Cancel racing the publish must not block
+	// or panic.
+	time.Sleep(time.Millisecond)
+	sub.Cancel()
+	wg.Wait()
+}
+
+func TestFeedConcurrentSubscribePublish(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	msg := binary.Message{Topic: "test", Payload: []byte("a")}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ch := make(chan binary.Message, 4)
+			sub := b.SubscribeChan("test", ch)
+			defer sub.Cancel()
+			b.Publish("test", msg)
+		}()
+	}
+	wg.Wait()
+}
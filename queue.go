@@ -0,0 +1,148 @@
+package bus
+
+import (
+	"sync/atomic"
+
+	"github.com/tinywasm/binary"
+)
+
+// OverflowPolicy controls what happens when a handler subscriber's
+// delivery queue is full at publish time.
+type OverflowPolicy int
+
+const (
+	// DropNewest discards the message being published, leaving the queue
+	// untouched. This is the default.
+	DropNewest OverflowPolicy = iota
+
+	// DropOldest discards the oldest queued message to make room for the
+	// one being published.
+	DropOldest
+
+	// Block makes Publish wait for room in the queue. Use with care: a
+	// stalled handler will stall the publisher too.
+	Block
+)
+
+const defaultQueueSize = 16
+
+type subscribeConfig struct {
+	queueSize int
+	policy    OverflowPolicy
+}
+
+// SubscribeOption configures queueing behavior for a Subscribe call.
+type SubscribeOption func(*subscribeConfig)
+
+// WithQueueSize sets the capacity of the subscriber's delivery queue.
+func WithQueueSize(n int) SubscribeOption {
+	return func(c *subscribeConfig) { c.queueSize = n }
+}
+
+// WithOverflowPolicy sets what happens when the delivery queue is full.
+func WithOverflowPolicy(p OverflowPolicy) SubscribeOption {
+	return func(c *subscribeConfig) { c.policy = p }
+}
+
+// QueuedSubscription is returned by Subscribe. It extends Subscription
+// with queue observability so callers can detect a handler that isn't
+// keeping up.
+type QueuedSubscription interface {
+	Subscription
+
+	// Depth returns the number of messages currently queued.
+	Depth() int
+
+	// Dropped returns the number of messages discarded by the overflow
+	// policy because the queue was full.
+	Dropped() uint64
+}
+
+type queuedSubscription struct {
+	subscription
+	queue   chan binary.Message
+	dropped *uint64
+}
+
+func (s *queuedSubscription) Depth() int {
+	return len(s.queue)
+}
+
+func (s *queuedSubscription) Dropped() uint64 {
+	return atomic.LoadUint64(s.dropped)
+}
+
+// enqueue applies the subscriber's overflow policy to msg. Publish calls
+// this after releasing the bus's read lock, so a Block-policy subscriber
+// blocking here only stalls its own delivery, not other Subscribe/Cancel/
+// Publish calls.
+//
+// A Publish can still snapshot a subscriber from the trie moments before
+// a concurrent Cancel/Close removes it and its worker exits, so enqueue
+// checks done up front: once it's closed there's no worker left to ever
+// drain s.queue, and writing msg there would silently strand it forever
+// instead of counting it as dropped.
+func (s *subscriber) enqueue(msg binary.Message) {
+	select {
+	case <-s.done:
+		atomic.AddUint64(s.dropped, 1)
+		return
+	default:
+	}
+
+	switch s.policy {
+	case Block:
+		select {
+		case s.queue <- msg:
+		case <-s.done:
+			atomic.AddUint64(s.dropped, 1)
+		}
+	case DropOldest:
+		for {
+			select {
+			case s.queue <- msg:
+				return
+			default:
+			}
+			select {
+			case <-s.queue:
+				atomic.AddUint64(s.dropped, 1)
+			default:
+				// Worker drained the queue between our two selects; retry.
+			}
+		}
+	default: // DropNewest
+		select {
+		case s.queue <- msg:
+		default:
+			atomic.AddUint64(s.dropped, 1)
+		}
+	}
+}
+
+// runQueueWorker delivers queued messages to handler one at a time until
+// the queue is closed or done fires, draining whatever is already queued
+// before it exits.
+func runQueueWorker(queue chan binary.Message, done chan struct{}, handler func(msg binary.Message)) {
+	for {
+		select {
+		case msg, ok := <-queue:
+			if !ok {
+				return
+			}
+			handler(msg)
+			continue
+		default:
+		}
+
+		select {
+		case msg, ok := <-queue:
+			if !ok {
+				return
+			}
+			handler(msg)
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package bus
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/binary"
+)
+
+func TestQueueDropNewest(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	block := make(chan struct{})
+	var handled int
+	var mu sync.Mutex
+	h := func(msg binary.Message) {
+		<-block // first delivery stalls the worker so the queue fills up
+		mu.Lock()
+		handled++
+		mu.Unlock()
+	}
+
+	sub := b.Subscribe("test", h, WithQueueSize(1))
+	msg := binary.Message{Topic: "test", Payload: []byte("a")}
+
+	// First publish is picked up by the worker immediately and blocks on
+	// <-block; the next two fill and then overflow the size-1 queue.
+	for i := 0; i < 3; i++ {
+		if err := b.Publish("test", msg); err != nil {
+			t.Fatalf("publish failed: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	qsub, ok := sub.(QueuedSubscription)
+	if !ok {
+		t.Fatalf("expected QueuedSubscription, got %T", sub)
+	}
+	if got := qsub.Dropped(); got != 1 {
+		t.Errorf("expected 1 dropped message, got %d", got)
+	}
+
+	close(block)
+}
+
+func TestQueueCancelStopsWorker(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	var calls int
+	var mu sync.Mutex
+	h := func(msg binary.Message) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+	}
+
+	sub := b.Subscribe("test", h, WithQueueSize(4))
+	sub.Cancel()
+
+	msg := binary.Message{Topic: "test", Payload: []byte("a")}
+	if err := b.Publish("test", msg); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	time.Sleep(time.Millisecond)
+	mu.Lock()
+	got := calls
+	mu.Unlock()
+	if got != 0 {
+		t.Errorf("expected handler not to run after cancel, got %d calls", got)
+	}
+}
+
+func TestQueueBlockPolicyUnblocksOnCancel(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	block := make(chan struct{})
+	h := func(msg binary.Message) { <-block }
+
+	sub := b.Subscribe("test", h, WithQueueSize(1), WithOverflowPolicy(Block))
+	msg := binary.Message{Topic: "test", Payload: []byte("a")}
+
+	// First delivery stalls the worker; second fills the queue; third
+	// would block forever under the Block policy until Cancel fires.
+	b.Publish("test", msg)
+	b.Publish("test", msg)
+	time.Sleep(time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		b.Publish("test", msg)
+		close(done)
+	}()
+
+	time.Sleep(time.Millisecond)
+	sub.Cancel()
+	close(block)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish did not return after Cancel with Block policy")
+	}
+}
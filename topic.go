@@ -0,0 +1,181 @@
+package bus
+
+// splitTopic breaks a topic into its dot- or slash-separated segments, e.g.
+// "sensors/room1/temp" and "sensors.room1.temp" both become
+// ["sensors", "room1", "temp"].
+func splitTopic(topic string) []string {
+	segments := make([]string, 0, 4)
+	start := 0
+	for i := 0; i < len(topic); i++ {
+		if c := topic[i]; c == '.' || c == '/' {
+			segments = append(segments, topic[start:i])
+			start = i + 1
+		}
+	}
+	return append(segments, topic[start:])
+}
+
+// topicNode is one segment level of the subscription trie. Publish walks
+// a node per topic segment (O(depth)) instead of scanning every
+// registered topic, and a nil *topicNode is a valid empty node so
+// matching and removal need no existence checks on the way down.
+type topicNode struct {
+	children map[string]*topicNode // exact-match fast path, keyed by segment
+	star     *topicNode            // "*" wildcard: matches exactly one segment
+	subs     []subscriber          // subscribers whose pattern ends exactly here
+	hashSubs []subscriber          // subscribers whose pattern ends in "#" here
+}
+
+func (n *topicNode) childFor(seg string) *topicNode {
+	if n.children == nil {
+		n.children = make(map[string]*topicNode)
+	}
+	child, ok := n.children[seg]
+	if !ok {
+		child = &topicNode{}
+		n.children[seg] = child
+	}
+	return child
+}
+
+// insert registers sub under the pattern described by the remaining
+// segments.
+func (n *topicNode) insert(segments []string, sub subscriber) {
+	if len(segments) == 0 {
+		n.subs = append(n.subs, sub)
+		return
+	}
+
+	switch seg := segments[0]; seg {
+	case "#":
+		n.hashSubs = append(n.hashSubs, sub)
+	case "*":
+		if n.star == nil {
+			n.star = &topicNode{}
+		}
+		n.star.insert(segments[1:], sub)
+	default:
+		n.childFor(seg).insert(segments[1:], sub)
+	}
+}
+
+// match appends every subscriber whose pattern matches segments to out.
+// "#" matches the remainder of the topic (zero or more segments); "*"
+// matches exactly one.
+func (n *topicNode) match(segments []string, out *[]subscriber) {
+	if n == nil {
+		return
+	}
+
+	*out = append(*out, n.hashSubs...)
+
+	if len(segments) == 0 {
+		*out = append(*out, n.subs...)
+		return
+	}
+
+	if n.children != nil {
+		if child, ok := n.children[segments[0]]; ok {
+			child.match(segments[1:], out)
+		}
+	}
+	n.star.match(segments[1:], out)
+}
+
+// remove deletes the subscriber with id registered under the pattern
+// described by the remaining segments. It reports whether a subscriber
+// was found. Any node left with no subs, hashSubs, children or star after
+// the removal is unlinked from its parent, so a churn of short-lived
+// high-cardinality topics (e.g. "sensors/<deviceID>/temp") doesn't leave
+// the trie growing forever.
+func (n *topicNode) remove(segments []string, id uint32) bool {
+	if n == nil {
+		return false
+	}
+
+	if len(segments) == 0 {
+		for i, s := range n.subs {
+			if s.id == id {
+				n.subs = append(n.subs[:i], n.subs[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+
+	switch seg := segments[0]; seg {
+	case "#":
+		for i, s := range n.hashSubs {
+			if s.id == id {
+				n.hashSubs = append(n.hashSubs[:i], n.hashSubs[i+1:]...)
+				return true
+			}
+		}
+		return false
+	case "*":
+		removed := n.star.remove(segments[1:], id)
+		if removed && n.star.empty() {
+			n.star = nil
+		}
+		return removed
+	default:
+		child, ok := n.children[seg]
+		if !ok {
+			return false
+		}
+		removed := child.remove(segments[1:], id)
+		if removed && child.empty() {
+			delete(n.children, seg)
+			if len(n.children) == 0 {
+				n.children = nil
+			}
+		}
+		return removed
+	}
+}
+
+// empty reports whether n has nothing left worth keeping: no subscribers
+// of its own and no children that could lead to any.
+func (n *topicNode) empty() bool {
+	return n != nil && len(n.subs) == 0 && len(n.hashSubs) == 0 && len(n.children) == 0 && n.star == nil
+}
+
+// collectDone appends the done channel of every handler subscriber
+// reachable from n to out, for Close to shut down their worker goroutines.
+func (n *topicNode) collectDone(out *[]chan struct{}) {
+	if n == nil {
+		return
+	}
+	for _, s := range n.subs {
+		if s.done != nil {
+			*out = append(*out, s.done)
+		}
+	}
+	for _, s := range n.hashSubs {
+		if s.done != nil {
+			*out = append(*out, s.done)
+		}
+	}
+	for _, c := range n.children {
+		c.collectDone(out)
+	}
+	n.star.collectDone(out)
+}
+
+// collectPatterns gathers the original pattern string of every subscriber
+// reachable from n into seen.
+func (n *topicNode) collectPatterns(seen map[string]struct{}) {
+	if n == nil {
+		return
+	}
+	for _, s := range n.subs {
+		seen[s.pattern] = struct{}{}
+	}
+	for _, s := range n.hashSubs {
+		seen[s.pattern] = struct{}{}
+	}
+	for _, c := range n.children {
+		c.collectPatterns(seen)
+	}
+	n.star.collectPatterns(seen)
+}
@@ -0,0 +1,110 @@
+package bus
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/binary"
+)
+
+func TestTopicSingleSegmentWildcard(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	var mu sync.Mutex
+	var got []string
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	b.Subscribe("sensors/*/temp", func(msg binary.Message) {
+		mu.Lock()
+		got = append(got, string(msg.Payload))
+		mu.Unlock()
+		wg.Done()
+	})
+
+	msg := binary.Message{Topic: "sensors/room1/temp", Payload: []byte("21C")}
+	if err := b.Publish("sensors/room1/temp", msg); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	wg.Wait()
+
+	if len(got) != 1 || got[0] != "21C" {
+		t.Errorf("expected [21C], got %v", got)
+	}
+
+	// A topic with an extra segment shouldn't match the single-level
+	// wildcard.
+	if err := b.Publish("sensors/room1/humidity/temp", msg); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	mu.Lock()
+	n := len(got)
+	mu.Unlock()
+	if n != 1 {
+		t.Errorf("expected no delivery for a longer topic, got %d total", n)
+	}
+}
+
+func TestTopicMultiLevelWildcard(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	var mu sync.Mutex
+	var got []string
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+	b.Subscribe("logs.#", func(msg binary.Message) {
+		mu.Lock()
+		got = append(got, msg.Topic)
+		mu.Unlock()
+		wg.Done()
+	})
+
+	if err := b.Publish("logs.app.error", binary.Message{Topic: "logs.app.error"}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	if err := b.Publish("logs.warn", binary.Message{Topic: "logs.warn"}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	wg.Wait()
+
+	sort.Strings(got)
+	if len(got) != 2 || got[0] != "logs.app.error" || got[1] != "logs.warn" {
+		t.Errorf("unexpected matches: %v", got)
+	}
+}
+
+func TestTopicExactMatchUnaffectedByWildcards(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	b.Subscribe("sensors/room1/temp", func(msg binary.Message) { wg.Done() })
+	b.Subscribe("sensors/*/humidity", func(msg binary.Message) {
+		t.Error("wildcard subscriber on a different leaf segment should not fire")
+	})
+
+	msg := binary.Message{Topic: "sensors/room1/temp"}
+	if err := b.Publish("sensors/room1/temp", msg); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	wg.Wait()
+
+	topics := b.Topics()
+	sort.Strings(topics)
+	want := []string{"sensors/*/humidity", "sensors/room1/temp"}
+	if len(topics) != len(want) {
+		t.Fatalf("expected topics %v, got %v", want, topics)
+	}
+	for i := range want {
+		if topics[i] != want[i] {
+			t.Errorf("expected topics %v, got %v", want, topics)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package bus
+
+import (
+	"encoding/binary"
+	"reflect"
+	"sync"
+
+	wire "github.com/tinywasm/binary"
+)
+
+// typedPendingCap bounds how many PublishT values a typed registry keeps,
+// evicting the oldest first, so the registry can't grow forever. Handlers
+// read values without removing them, so an entry stays until evicted.
+const typedPendingCap = 1024
+
+// Typed layers a strongly-typed, marshalling-free publish/subscribe API
+// for topic on top of an existing Bus. PublishT and SubscribeT still go
+// through the Bus's normal Subscribe/Publish path — so queueing and
+// overflow policies apply to typed topics too — but the message itself
+// travels as an in-memory value keyed by a small correlation ID instead of
+// a binary.Message-encoded payload. A SubscribeT handler resolves IDs only
+// in the registry for its own topic string, so a wildcard topic does not
+// receive values published through a Typed[M] for a concrete topic.
+type Typed[M any] struct {
+	bus   Bus
+	topic string
+	state *typedState[M]
+}
+
+// typedState holds the correlation-ID registry for one (topic, M)
+// combination on a single bus. When that bus was created by New, it's
+// shared across every *Typed[M] built from NewTyped with the same bus and
+// topic, so independently constructed
+// Typed wrappers still interoperate: one can PublishT and another can
+// SubscribeT for the same logical topic.
+type typedState[M any] struct {
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]M
+	order   []uint64 // insertion order of pending, for FIFO eviction
+}
+
+// typedStateKey identifies a typed registry within a single bus. The
+// reflect.Type is included so that reusing a topic with a different M
+// can't silently alias two unrelated registries.
+type typedStateKey struct {
+	topic string
+	typ   reflect.Type
+}
+
+// typedStateFor returns the typedState shared by every Typed[M] built for
+// b's topic, creating it on first use. When b is a *bus, the registry is
+// owned by b and is discarded for good when b.Close() runs. For any other
+// Bus implementation — one whose lifecycle this package doesn't own —
+// state is private to this Typed[M] instance instead: two separately
+// constructed wrappers over a foreign Bus won't observe each other's
+// PublishT/SubscribeT calls, but nothing leaks past their own lifetime
+// either.
+func typedStateFor[M any](b Bus, topic string) *typedState[M] {
+	bb, ok := b.(*bus)
+	if !ok {
+		return &typedState[M]{pending: make(map[uint64]M)}
+	}
+
+	key := typedStateKey{topic: topic, typ: reflect.TypeOf((*M)(nil)).Elem()}
+
+	bb.typedMu.Lock()
+	defer bb.typedMu.Unlock()
+
+	if existing, ok := bb.typed[key]; ok {
+		return existing.(*typedState[M])
+	}
+	fresh := &typedState[M]{pending: make(map[uint64]M)}
+	if bb.typed == nil {
+		bb.typed = make(map[typedStateKey]interface{})
+	}
+	bb.typed[key] = fresh
+	return fresh
+}
+
+// NewTyped creates a Typed[M] that publishes and subscribes on topic
+// using b's subscription registry. When b was created by New, every
+// Typed[M] created for the same b, topic and M shares one underlying
+// correlation-ID registry; for any other Bus each Typed[M] has its own.
+func NewTyped[M any](b Bus, topic string) *Typed[M] {
+	return &Typed[M]{bus: b, topic: topic, state: typedStateFor[M](b, topic)}
+}
+
+// SubscriptionT is the typed counterpart of Subscription returned by
+// SubscribeT.
+type SubscriptionT[M any] struct {
+	Subscription
+}
+
+// PublishT stashes msg and publishes a correlation ID for it on the
+// underlying Bus; every SubscribeT handler for this topic resolves the ID
+// back to msg without any encoding of M itself.
+func (t *Typed[M]) PublishT(msg M) error {
+	st := t.state
+	st.mu.Lock()
+	st.nextID++
+	id := st.nextID
+	st.pending[id] = msg
+	st.order = append(st.order, id)
+	if len(st.order) > typedPendingCap {
+		oldest := st.order[0]
+		st.order = st.order[1:]
+		delete(st.pending, oldest)
+	}
+	st.mu.Unlock()
+
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, id)
+	return t.bus.Publish(t.topic, wire.Message{Topic: t.topic, Payload: payload})
+}
+
+// SubscribeT registers handler to receive values published with PublishT.
+// opts configure the underlying queue exactly as with Subscribe.
+func (t *Typed[M]) SubscribeT(handler func(msg M), opts ...SubscribeOption) SubscriptionT[M] {
+	st := t.state
+	sub := t.bus.Subscribe(t.topic, func(raw wire.Message) {
+		if len(raw.Payload) != 8 {
+			return // not one of ours: ignore rather than panic on a foreign publish
+		}
+		id := binary.BigEndian.Uint64(raw.Payload)
+
+		st.mu.Lock()
+		msg, ok := st.pending[id]
+		st.mu.Unlock()
+		if !ok {
+			return // evicted before this handler got to it
+		}
+		handler(msg)
+	}, opts...)
+
+	return SubscriptionT[M]{Subscription: sub}
+}
@@ -0,0 +1,71 @@
+package bus
+
+import (
+	"sync"
+	"testing"
+)
+
+type testEvent struct {
+	Name  string
+	Count int
+}
+
+func TestTypedPublishSubscribe(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	typed := NewTyped[testEvent](b, "events")
+
+	var mu sync.Mutex
+	var got []testEvent
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	sub := typed.SubscribeT(func(msg testEvent) {
+		mu.Lock()
+		got = append(got, msg)
+		mu.Unlock()
+		wg.Done()
+	})
+	defer sub.Cancel()
+
+	if err := typed.PublishT(testEvent{Name: "a", Count: 1}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	if err := typed.PublishT(testEvent{Name: "b", Count: 2}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 || got[0].Name != "a" || got[1].Name != "b" {
+		t.Errorf("unexpected typed delivery: %v", got)
+	}
+}
+
+func TestTypedCancelStopsDelivery(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	typed := NewTyped[testEvent](b, "events")
+
+	var calls int
+	var mu sync.Mutex
+	sub := typed.SubscribeT(func(msg testEvent) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+	})
+	sub.Cancel()
+
+	if err := typed.PublishT(testEvent{Name: "a"}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 0 {
+		t.Errorf("expected no delivery after cancel, got %d calls", calls)
+	}
+}
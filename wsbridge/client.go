@@ -0,0 +1,273 @@
+package wsbridge
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tinywasm/binary"
+	bus "github.com/tinywasm/bus"
+)
+
+const (
+	clientSendQueueSize = 256
+	reconnectMinDelay   = 500 * time.Millisecond
+	reconnectMaxDelay   = 30 * time.Second
+)
+
+var errNotConnected = errors.New("wsbridge: not connected to server")
+
+// NewClient dials url (ws:// or wss://) and returns a bus.Bus backed by
+// the connection: Subscribe/SubscribeChan register locally and tell the
+// server which topics to relay, and Publish forwards to the server for
+// fan-out to every subscriber of the topic, including this client's own
+// subscriptions, which receive the message back from the server. The
+// connection is (re)established in
+// the background; NewClient returns immediately and Subscribe calls made
+// before the first connection succeeds are queued and sent once it does.
+// On disconnect the client reconnects automatically and resubscribes to
+// every topic it was tracking.
+//
+// Subscriptions returned by a client do not support the queue-depth or
+// drop-count observability that a local bus.Bus exposes; only Topic and
+// Cancel are guaranteed.
+func NewClient(url string) bus.Bus {
+	c := &client{
+		url:    url,
+		local:  bus.New(),
+		closed: make(chan struct{}),
+		send:   make(chan outFrame, clientSendQueueSize),
+		topics: make(map[string]int),
+	}
+	go c.connectLoop()
+	return c
+}
+
+type client struct {
+	url   string
+	local bus.Bus
+
+	send      chan outFrame
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu     sync.Mutex
+	conn   net.Conn // nil while disconnected
+	topics map[string]int
+}
+
+func (c *client) Subscribe(topic string, handler func(msg binary.Message), opts ...bus.SubscribeOption) bus.Subscription {
+	c.trackTopic(topic)
+	return c.wrap(topic, c.local.Subscribe(topic, handler, opts...))
+}
+
+func (c *client) SubscribeChan(topic string, ch chan<- binary.Message) bus.Subscription {
+	c.trackTopic(topic)
+	return c.wrap(topic, c.local.SubscribeChan(topic, ch))
+}
+
+func (c *client) SubscribeWithReplay(topic string, handler func(msg binary.Message), n int, opts ...bus.SubscribeOption) bus.Subscription {
+	c.trackTopic(topic)
+	return c.wrap(topic, c.local.SubscribeWithReplay(topic, handler, n, opts...))
+}
+
+func (c *client) Publish(topic string, msg binary.Message) error {
+	data, err := encodeEnvelope(envelope{Op: opPub, Topic: topic, Payload: msg.Payload})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case c.send <- outFrame{op: opText, payload: data}:
+		return nil
+	case <-c.closed:
+		return errNotConnected
+	default:
+		return errors.New("wsbridge: client send queue full")
+	}
+}
+
+func (c *client) Topics() []string {
+	return c.local.Topics()
+}
+
+func (c *client) MessageCounts() map[string]int {
+	return c.local.MessageCounts()
+}
+
+func (c *client) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.mu.Lock()
+		if c.conn != nil {
+			c.conn.Close()
+		}
+		c.mu.Unlock()
+	})
+	return c.local.Close()
+}
+
+// trackTopic records that the client wants topic relayed, and sends the
+// "sub" frame immediately if connected. The same bookkeeping resubscribes
+// every tracked topic after a reconnect.
+func (c *client) trackTopic(topic string) {
+	c.mu.Lock()
+	first := c.topics[topic] == 0
+	c.topics[topic]++
+	c.mu.Unlock()
+
+	if first {
+		c.sendOp(opSub, topic)
+	}
+}
+
+func (c *client) untrackTopic(topic string) {
+	c.mu.Lock()
+	c.topics[topic]--
+	last := c.topics[topic] <= 0
+	if last {
+		delete(c.topics, topic)
+	}
+	c.mu.Unlock()
+
+	if last {
+		c.sendOp(opUnsub, topic)
+	}
+}
+
+func (c *client) sendOp(op op, topic string) {
+	data, err := encodeEnvelope(envelope{Op: op, Topic: topic})
+	if err != nil {
+		return
+	}
+	select {
+	case c.send <- outFrame{op: opText, payload: data}:
+	case <-c.closed:
+	default:
+		// Best-effort: if the queue is momentarily full, a dropped "sub"
+		// leaves the topic tracked, so it is resent on the next reconnect;
+		// a dropped "unsub" leaves the server relaying the topic until then.
+	}
+}
+
+func (c *client) wrap(topic string, sub bus.Subscription) bus.Subscription {
+	return &clientSubscription{Subscription: sub, client: c, topic: topic}
+}
+
+type clientSubscription struct {
+	bus.Subscription
+	client *client
+	topic  string
+	once   sync.Once
+}
+
+func (s *clientSubscription) Cancel() {
+	s.once.Do(func() {
+		s.Subscription.Cancel()
+		s.client.untrackTopic(s.topic)
+	})
+}
+
+// connectLoop dials the server with exponential backoff, and on every
+// successful connection relays c.send until the connection drops, then
+// retries.
+func (c *client) connectLoop() {
+	delay := reconnectMinDelay
+	for {
+		select {
+		case <-c.closed:
+			return
+		default:
+		}
+
+		conn, err := dial(c.url)
+		if err != nil {
+			select {
+			case <-time.After(delay):
+			case <-c.closed:
+				return
+			}
+			if delay *= 2; delay > reconnectMaxDelay {
+				delay = reconnectMaxDelay
+			}
+			continue
+		}
+		delay = reconnectMinDelay
+
+		c.mu.Lock()
+		c.conn = conn
+		c.mu.Unlock()
+
+		c.resubscribeAll()
+		c.runConnection(conn)
+
+		c.mu.Lock()
+		if c.conn == conn {
+			c.conn = nil
+		}
+		c.mu.Unlock()
+	}
+}
+
+func (c *client) resubscribeAll() {
+	c.mu.Lock()
+	topics := make([]string, 0, len(c.topics))
+	for topic := range c.topics {
+		topics = append(topics, topic)
+	}
+	c.mu.Unlock()
+
+	for _, topic := range topics {
+		c.sendOp(opSub, topic)
+	}
+}
+
+// runConnection pumps frames for one connection attempt until it drops or
+// the client is closed.
+func (c *client) runConnection(conn net.Conn) {
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case f := <-c.send:
+				if err := writeFrame(conn, f.op, f.payload, true); err != nil {
+					conn.Close()
+					return
+				}
+			case <-done:
+				return
+			case <-c.closed:
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	for {
+		op, payload, err := readFrame(conn)
+		if err != nil {
+			conn.Close()
+			return
+		}
+
+		switch op {
+		case opPing:
+			select {
+			case c.send <- outFrame{op: opPong}:
+			default:
+			}
+		case opClose:
+			conn.Close()
+			return
+		case opText:
+			env, err := decodeEnvelope(payload)
+			if err != nil || env.Op != opPub {
+				continue
+			}
+			c.local.Publish(env.Topic, binary.Message{Topic: env.Topic, Payload: env.Payload})
+		}
+	}
+}
@@ -0,0 +1,7 @@
+// Package wsbridge exposes a bus.Bus over a WebSocket connection so remote
+// clients (browser WASM, other processes) can Subscribe and Publish on
+// named topics without sharing a process. It speaks a small JSON protocol
+// ({"op":"sub"|"unsub"|"pub", "topic":"...", "payload":...}) over hand-rolled
+// RFC 6455 framing, keeping the dependency footprint at the Go standard
+// library only.
+package wsbridge
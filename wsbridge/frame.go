@@ -0,0 +1,128 @@
+package wsbridge
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+)
+
+// opcode is a WebSocket frame opcode, as defined by RFC 6455 section 5.2.
+type opcode byte
+
+const (
+	opContinuation opcode = 0x0
+	opText         opcode = 0x1
+	opBinary       opcode = 0x2
+	opClose        opcode = 0x8
+	opPing         opcode = 0x9
+	opPong         opcode = 0xA
+)
+
+const maxFramePayload = 1 << 20 // 1 MiB: generous for JSON control/data frames
+
+var errFrameTooLarge = errors.New("wsbridge: frame payload exceeds maxFramePayload")
+
+// writeFrame writes a single, unfragmented, final WebSocket frame. Clients
+// MUST mask their frames; servers MUST NOT (RFC 6455 section 5.1).
+func writeFrame(w io.Writer, op opcode, payload []byte, mask bool) error {
+	var header [14]byte
+	header[0] = 0x80 | byte(op) // FIN=1, opcode
+
+	maskBit := byte(0)
+	if mask {
+		maskBit = 0x80
+	}
+
+	n := len(payload)
+	var headerLen int
+	switch {
+	case n <= 125:
+		header[1] = maskBit | byte(n)
+		headerLen = 2
+	case n <= 0xFFFF:
+		header[1] = maskBit | 126
+		binary.BigEndian.PutUint16(header[2:4], uint16(n))
+		headerLen = 4
+	default:
+		header[1] = maskBit | 127
+		binary.BigEndian.PutUint64(header[2:10], uint64(n))
+		headerLen = 10
+	}
+
+	if mask {
+		var key [4]byte
+		rand.Read(key[:])
+		copy(header[headerLen:headerLen+4], key[:])
+		headerLen += 4
+
+		if _, err := w.Write(header[:headerLen]); err != nil {
+			return err
+		}
+		masked := make([]byte, n)
+		for i, b := range payload {
+			masked[i] = b ^ key[i%4]
+		}
+		_, err := w.Write(masked)
+		return err
+	}
+
+	if _, err := w.Write(header[:headerLen]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// readFrame reads a single, unfragmented WebSocket frame and returns its
+// opcode and unmasked payload. It does not support fragmented messages,
+// which this package never sends or expects.
+func readFrame(r io.Reader) (opcode, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+
+	op := opcode(head[0] & 0x0F)
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if length > maxFramePayload {
+		return 0, nil, errFrameTooLarge
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, key[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= key[i%4]
+		}
+	}
+
+	return op, payload, nil
+}
@@ -0,0 +1,143 @@
+package wsbridge
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// websocketGUID is the magic value RFC 6455 section 1.3 defines for
+// deriving Sec-WebSocket-Accept from Sec-WebSocket-Key.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+func acceptKey(clientKey string) string {
+	sum := sha1.Sum([]byte(clientKey + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// upgrade validates an incoming HTTP request as a WebSocket handshake,
+// hijacks the connection, and writes the 101 response. The returned
+// net.Conn is ready for WebSocket framing.
+func upgrade(w http.ResponseWriter, r *http.Request) (net.Conn, error) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		http.Error(w, "expected websocket upgrade", http.StatusBadRequest)
+		return nil, errors.New("wsbridge: not a websocket upgrade request")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("wsbridge: missing Sec-WebSocket-Key")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection does not support hijacking", http.StatusInternalServerError)
+		return nil, errors.New("wsbridge: ResponseWriter does not support hijacking")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(resp)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// dial performs a client-side WebSocket handshake against rawURL (ws:// or
+// wss://) and returns the raw connection ready for WebSocket framing.
+func dial(rawURL string) (net.Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn
+	host := u.Host
+	switch u.Scheme {
+	case "ws":
+		if !strings.Contains(host, ":") {
+			host += ":80"
+		}
+		conn, err = net.Dial("tcp", host)
+	case "wss":
+		if !strings.Contains(host, ":") {
+			host += ":443"
+		}
+		conn, err = tls.Dial("tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("wsbridge: unsupported scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keyBytes := make([]byte, 16)
+	if _, err := rand.Read(keyBytes); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	req := "GET " + path + " HTTP/1.1\r\n" +
+		"Host: " + u.Host + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: "GET"})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("wsbridge: handshake failed: %s", resp.Status)
+	}
+	if want := acceptKey(key); resp.Header.Get("Sec-WebSocket-Accept") != want {
+		conn.Close()
+		return nil, errors.New("wsbridge: invalid Sec-WebSocket-Accept")
+	}
+
+	if br.Buffered() > 0 {
+		// No frames can have arrived before the 101 response; a peer that
+		// pipelines one is misbehaving.
+		conn.Close()
+		return nil, errors.New("wsbridge: unexpected data after handshake")
+	}
+	return conn, nil
+}
@@ -0,0 +1,31 @@
+package wsbridge
+
+import "encoding/json"
+
+// op identifies the kind of envelope exchanged between client and server.
+type op string
+
+const (
+	opSub   op = "sub"
+	opUnsub op = "unsub"
+	opPub   op = "pub"
+)
+
+// envelope is the wire format for every message exchanged over the
+// bridge: {"op":"sub","topic":"..."} or {"op":"pub","topic":"...","payload":...}.
+// encoding/json base64-encodes the Payload []byte automatically.
+type envelope struct {
+	Op      op     `json:"op"`
+	Topic   string `json:"topic"`
+	Payload []byte `json:"payload,omitempty"`
+}
+
+func encodeEnvelope(e envelope) ([]byte, error) {
+	return json.Marshal(e)
+}
+
+func decodeEnvelope(data []byte) (envelope, error) {
+	var e envelope
+	err := json.Unmarshal(data, &e)
+	return e, err
+}
@@ -0,0 +1,231 @@
+package wsbridge
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tinywasm/binary"
+	bus "github.com/tinywasm/bus"
+)
+
+const (
+	serverPingInterval = 30 * time.Second
+	serverPongTimeout  = 2 * serverPingInterval
+
+	// relayQueueSize bounds how many unsent pub frames a single topic
+	// subscription buffers before Publish starts dropping for this
+	// connection; a slow remote client must not stall the bus.
+	relayQueueSize = 64
+)
+
+// NewServer returns an http.Handler that upgrades incoming requests to
+// WebSocket connections and bridges them to b: a "sub" frame subscribes
+// the connection to a topic, "unsub" cancels it, and "pub" publishes a
+// message. Every message the connection is subscribed to arrives back as
+// a "pub" frame.
+func NewServer(b bus.Bus) http.Handler {
+	return &server{bus: b}
+}
+
+type server struct {
+	bus bus.Bus
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	raw, err := upgrade(w, r)
+	if err != nil {
+		return
+	}
+	newServerConn(raw, s.bus).serve()
+}
+
+type outFrame struct {
+	op      opcode
+	payload []byte
+}
+
+type serverConn struct {
+	ws  net.Conn
+	bus bus.Bus
+
+	send      chan outFrame
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu   sync.Mutex
+	subs map[string]*relayedSub
+}
+
+// relayedSub pairs a bus subscription with the channel and stop signal
+// backing its relay goroutine. The channel is never closed: the bus may
+// still be mid-send on it when unsubscribe runs, so only the relay
+// goroutine's own stop channel is used to end it.
+type relayedSub struct {
+	sub  bus.Subscription
+	ch   chan binary.Message
+	stop chan struct{}
+}
+
+func newServerConn(ws net.Conn, b bus.Bus) *serverConn {
+	return &serverConn{
+		ws:     ws,
+		bus:    b,
+		send:   make(chan outFrame, relayQueueSize),
+		closed: make(chan struct{}),
+		subs:   make(map[string]*relayedSub),
+	}
+}
+
+func (c *serverConn) serve() {
+	go c.writeLoop()
+	go c.pingLoop()
+
+	defer c.close()
+
+	for {
+		c.ws.SetReadDeadline(time.Now().Add(serverPongTimeout))
+		op, payload, err := readFrame(c.ws)
+		if err != nil {
+			return
+		}
+
+		switch op {
+		case opPing:
+			c.enqueue(opPong, nil)
+		case opPong:
+			// Read deadline already extended above; nothing else to do.
+		case opClose:
+			return
+		case opText:
+			env, err := decodeEnvelope(payload)
+			if err != nil {
+				log.Printf("wsbridge: server: bad frame: %v", err)
+				continue
+			}
+			c.handle(env)
+		}
+	}
+}
+
+func (c *serverConn) handle(env envelope) {
+	switch env.Op {
+	case opSub:
+		c.subscribe(env.Topic)
+	case opUnsub:
+		c.unsubscribe(env.Topic)
+	case opPub:
+		c.bus.Publish(env.Topic, binary.Message{Topic: env.Topic, Payload: env.Payload})
+	}
+}
+
+func (c *serverConn) subscribe(topic string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.subs[topic]; ok {
+		return
+	}
+
+	ch := make(chan binary.Message, relayQueueSize)
+	rs := &relayedSub{
+		sub:  c.bus.SubscribeChan(topic, ch),
+		ch:   ch,
+		stop: make(chan struct{}),
+	}
+	c.subs[topic] = rs
+
+	go func() {
+		for {
+			select {
+			case msg := <-ch:
+				data, err := encodeEnvelope(envelope{Op: opPub, Topic: msg.Topic, Payload: msg.Payload})
+				if err != nil {
+					continue
+				}
+				c.enqueueOrDrop(opText, data)
+			case <-rs.stop:
+				return
+			case <-c.closed:
+				return
+			}
+		}
+	}()
+}
+
+func (c *serverConn) unsubscribe(topic string) {
+	c.mu.Lock()
+	rs, ok := c.subs[topic]
+	delete(c.subs, topic)
+	c.mu.Unlock()
+
+	if ok {
+		rs.sub.Cancel()
+		close(rs.stop)
+	}
+}
+
+func (c *serverConn) writeLoop() {
+	for {
+		select {
+		case f := <-c.send:
+			if err := writeFrame(c.ws, f.op, f.payload, false); err != nil {
+				c.close()
+				return
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+func (c *serverConn) pingLoop() {
+	ticker := time.NewTicker(serverPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.enqueue(opPing, nil)
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// enqueue schedules a control frame for the write loop. Unlike
+// enqueueOrDrop, control frames are small and infrequent enough to wait
+// for a free slot rather than drop.
+func (c *serverConn) enqueue(op opcode, payload []byte) {
+	select {
+	case c.send <- outFrame{op: op, payload: payload}:
+	case <-c.closed:
+	}
+}
+
+func (c *serverConn) enqueueOrDrop(op opcode, payload []byte) {
+	select {
+	case c.send <- outFrame{op: op, payload: payload}:
+	case <-c.closed:
+	default:
+		// Slow reader: drop rather than block the topic's relay goroutine.
+	}
+}
+
+func (c *serverConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.ws.Close()
+
+		c.mu.Lock()
+		subs := c.subs
+		c.subs = nil
+		c.mu.Unlock()
+		for _, rs := range subs {
+			rs.sub.Cancel()
+			// No need to close rs.stop: the relay goroutine also selects
+			// on c.closed, which is already closed above.
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package wsbridge
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/binary"
+	bus "github.com/tinywasm/bus"
+)
+
+func TestWSBridgeRoundTrip(t *testing.T) {
+	serverBus := bus.New()
+	defer serverBus.Close()
+
+	srv := httptest.NewServer(NewServer(serverBus))
+	defer srv.Close()
+
+	url := "ws://" + strings.TrimPrefix(srv.URL, "http://")
+	client := NewClient(url)
+	defer client.Close()
+
+	var mu sync.Mutex
+	var received []string
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	sub := client.Subscribe("test", func(msg binary.Message) {
+		mu.Lock()
+		received = append(received, string(msg.Payload))
+		mu.Unlock()
+		wg.Done()
+	})
+	defer sub.Cancel()
+
+	// Give the background connection loop time to dial, handshake and
+	// send the "sub" frame before we publish.
+	waitForTopic(t, serverBus, "test")
+
+	if err := serverBus.Publish("test", binary.Message{Topic: "test", Payload: []byte("hello")}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	waitGroup(t, &wg)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || received[0] != "hello" {
+		t.Errorf("expected [hello], got %v", received)
+	}
+}
+
+func TestWSBridgeClientPublish(t *testing.T) {
+	serverBus := bus.New()
+	defer serverBus.Close()
+
+	srv := httptest.NewServer(NewServer(serverBus))
+	defer srv.Close()
+
+	url := "ws://" + strings.TrimPrefix(srv.URL, "http://")
+	client := NewClient(url)
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	serverSub := serverBus.Subscribe("test", func(msg binary.Message) { wg.Done() })
+	defer serverSub.Cancel()
+
+	// Publish is buffered and flushed once the background connection
+	// comes up, so the call below succeeds even if dialing hasn't
+	// finished yet.
+	if err := client.Publish("test", binary.Message{Topic: "test", Payload: []byte("hi")}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	waitGroup(t, &wg)
+}
+
+func waitForTopic(t *testing.T, b bus.Bus, topic string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		for _, got := range b.Topics() {
+			if got == topic {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("topic %q never subscribed", topic)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func waitGroup(t *testing.T, wg *sync.WaitGroup) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for message delivery")
+	}
+}